@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"github.com/alecthomas/kingpin/v2"
+
+	"github.com/kopia/kopia/policy"
+)
+
+// policySetActionProtocolFlags binds the `policy set` flags that select the action
+// protocol used by all action hooks configured on this policy.
+type policySetActionProtocolFlags struct {
+	actionProtocol      string
+	persistActionScript bool
+}
+
+func (c *policySetActionProtocolFlags) setup(cmd *kingpin.CmdClause) {
+	cmd.Flag("action-protocol", "Protocol used to communicate with action hooks").Default(string(policy.ActionProtocolEnvironment)).EnumVar(&c.actionProtocol, string(policy.ActionProtocolEnvironment), string(policy.ActionProtocolJSON))
+	cmd.Flag("persist-action-script", "Persist the provided inline script to a temporary file instead of passing it to the shell directly").BoolVar(&c.persistActionScript)
+}
+
+// applyToAll sets Protocol on every non-nil hook in ap.
+func (c *policySetActionProtocolFlags) applyToAll(ap *policy.ActionsPolicy) {
+	protocol := policy.ActionProtocol(c.actionProtocol)
+
+	for _, a := range []**policy.ActionCommand{
+		&ap.BeforeSnapshotRoot,
+		&ap.AfterSnapshotRoot,
+		&ap.BeforeFolder,
+		&ap.AfterFolder,
+		&ap.OnSnapshotError,
+		&ap.OnSnapshotCompletion,
+		&ap.BeforeRepositoryConnect,
+		&ap.AfterRepositoryDisconnect,
+	} {
+		if *a != nil {
+			(*a).Protocol = protocol
+			(*a).PersistScript = c.persistActionScript
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+
+	"github.com/kopia/kopia/policy"
+)
+
+// policySetErrorActionFlags binds the `policy set` flags for the on-snapshot-error,
+// on-snapshot-completion, before-repository-connect and after-repository-disconnect
+// action hooks.
+type policySetErrorActionFlags struct {
+	onSnapshotErrorAction           string
+	onSnapshotCompletionAction      string
+	beforeRepositoryConnectAction   string
+	afterRepositoryDisconnectAction string
+}
+
+func (c *policySetErrorActionFlags) setup(cmd *kingpin.CmdClause) {
+	cmd.Flag("on-snapshot-error-action", "Run the provided command when a snapshot fails").StringVar(&c.onSnapshotErrorAction)
+	cmd.Flag("on-snapshot-completion-action", "Run the provided command after every snapshot attempt, whether it succeeded or failed").StringVar(&c.onSnapshotCompletionAction)
+	cmd.Flag("before-repository-connect-action", "Run the provided command before connecting to the repository").StringVar(&c.beforeRepositoryConnectAction)
+	cmd.Flag("after-repository-disconnect-action", "Run the provided command after disconnecting from the repository").StringVar(&c.afterRepositoryDisconnectAction)
+}
+
+// apply sets the hooks named by the provided flags on ap, using mode/timeout as
+// configured by the existing --action-command-mode/--action-command-timeout flags.
+func (c *policySetErrorActionFlags) apply(ap *policy.ActionsPolicy, mode policy.ActionCommandMode, timeout time.Duration) {
+	setActionCommand(&ap.OnSnapshotError, c.onSnapshotErrorAction, mode, timeout)
+	setActionCommand(&ap.OnSnapshotCompletion, c.onSnapshotCompletionAction, mode, timeout)
+	setActionCommand(&ap.BeforeRepositoryConnect, c.beforeRepositoryConnectAction, mode, timeout)
+	setActionCommand(&ap.AfterRepositoryDisconnect, c.afterRepositoryDisconnectAction, mode, timeout)
+}
+
+func setActionCommand(dst **policy.ActionCommand, script string, mode policy.ActionCommandMode, timeout time.Duration) {
+	if script == "" {
+		return
+	}
+
+	*dst = &policy.ActionCommand{
+		Script:         script,
+		Mode:           mode,
+		TimeoutSeconds: int(timeout.Seconds()),
+	}
+}
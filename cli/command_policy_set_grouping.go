@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+// groupByFlag binds the `--group-by=host,path,tag` flag shared by `policy set` and
+// `snapshot list`, which selects the dimensions retention counters (and, for
+// `snapshot list`, display) are partitioned by.
+type groupByFlag struct {
+	groupBy string
+}
+
+func (c *groupByFlag) setup(cmd *kingpin.CmdClause) {
+	cmd.Flag("group-by", "Comma-separated grouping dimensions: host, username, path, tag (full tag set), tag:<name> (one named tag)").StringVar(&c.groupBy)
+}
+
+// parse returns the RetentionGrouping selected by --group-by, or nil if the flag was
+// not provided.
+func (c *groupByFlag) parse() (*snapshot.RetentionGrouping, error) {
+	if c.groupBy == "" {
+		return nil, nil
+	}
+
+	var g snapshot.RetentionGrouping
+
+	for _, dim := range strings.Split(c.groupBy, ",") {
+		dim = strings.TrimSpace(dim)
+
+		switch {
+		case dim == "host":
+			g.ByHost = true
+		case dim == "username":
+			g.ByUsername = true
+		case dim == "path":
+			g.ByPath = true
+		case dim == "tag":
+			g.ByAllTags = true
+		case strings.HasPrefix(dim, "tag:"):
+			g.ByTags = append(g.ByTags, strings.TrimPrefix(dim, "tag:"))
+		default:
+			return nil, fmt.Errorf("invalid --group-by dimension %q, must be one of host, username, path, tag, tag:<name>", dim)
+		}
+	}
+
+	return &g, nil
+}
+
+// apply sets rp.Grouping to the dimensions selected by --group-by, if the flag was
+// provided.
+func (c *groupByFlag) apply(rp *snapshot.RetentionPolicy) error {
+	g, err := c.parse()
+	if err != nil {
+		return err
+	}
+
+	if g != nil {
+		rp.Grouping = g
+	}
+
+	return nil
+}
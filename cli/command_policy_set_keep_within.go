@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+// policySetKeepWithinFlags binds the `policy set` flags for the six duration-based
+// KeepWithin* retention rules.
+type policySetKeepWithinFlags struct {
+	keepWithin        time.Duration
+	keepWithinHourly  time.Duration
+	keepWithinDaily   time.Duration
+	keepWithinWeekly  time.Duration
+	keepWithinMonthly time.Duration
+	keepWithinAnnual  time.Duration
+}
+
+func (c *policySetKeepWithinFlags) setup(cmd *kingpin.CmdClause) {
+	cmd.Flag("keep-within", "Unconditionally retain every snapshot newer than this (e.g. 720h for 30 days)").DurationVar(&c.keepWithin)
+	cmd.Flag("keep-within-hourly", "Retain the newest snapshot of each hour within this duration").DurationVar(&c.keepWithinHourly)
+	cmd.Flag("keep-within-daily", "Retain the newest snapshot of each day within this duration").DurationVar(&c.keepWithinDaily)
+	cmd.Flag("keep-within-weekly", "Retain the newest snapshot of each ISO week within this duration").DurationVar(&c.keepWithinWeekly)
+	cmd.Flag("keep-within-monthly", "Retain the newest snapshot of each month within this duration").DurationVar(&c.keepWithinMonthly)
+	cmd.Flag("keep-within-annual", "Retain the newest snapshot of each year within this duration").DurationVar(&c.keepWithinAnnual)
+}
+
+func (c *policySetKeepWithinFlags) apply(rp *snapshot.RetentionPolicy) {
+	applyDuration(&rp.KeepWithin, c.keepWithin)
+	applyDuration(&rp.KeepWithinHourly, c.keepWithinHourly)
+	applyDuration(&rp.KeepWithinDaily, c.keepWithinDaily)
+	applyDuration(&rp.KeepWithinWeekly, c.keepWithinWeekly)
+	applyDuration(&rp.KeepWithinMonthly, c.keepWithinMonthly)
+	applyDuration(&rp.KeepWithinAnnual, c.keepWithinAnnual)
+}
+
+func applyDuration(dst **time.Duration, d time.Duration) {
+	if d > 0 {
+		*dst = &d
+	}
+}
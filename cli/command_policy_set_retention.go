@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"github.com/alecthomas/kingpin/v2"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+// policySetKeepTagsFlags binds the `policy set --keep-tag` flag, which marks
+// snapshots bearing any of the given tags for unconditional retention.
+type policySetKeepTagsFlags struct {
+	keepTags []string
+}
+
+func (c *policySetKeepTagsFlags) setup(cmd *kingpin.CmdClause) {
+	cmd.Flag("keep-tag", "Retain snapshots carrying this tag regardless of other retention settings (can be repeated)").StringsVar(&c.keepTags)
+}
+
+func (c *policySetKeepTagsFlags) apply(rp *snapshot.RetentionPolicy) {
+	if len(c.keepTags) > 0 {
+		rp.KeepTags = c.keepTags
+	}
+}
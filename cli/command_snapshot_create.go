@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+)
+
+// snapshotCreateTagsFlags binds the `snapshot create --tags` flag, which attaches
+// arbitrary labels to the resulting snapshot manifest.
+type snapshotCreateTagsFlags struct {
+	tags []string
+}
+
+func (c *snapshotCreateTagsFlags) setup(cmd *kingpin.CmdClause) {
+	cmd.Flag("tags", "Comma-separated tags to attach to the new snapshot").Action(c.splitTags).StringsVar(&c.tags)
+}
+
+// splitTags splits each --tags value on commas, so that --tags=a,b yields two tags.
+func (c *snapshotCreateTagsFlags) splitTags(*kingpin.ParseContext) error {
+	var tags []string
+
+	for _, v := range c.tags {
+		tags = append(tags, strings.Split(v, ",")...)
+	}
+
+	c.tags = tags
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"github.com/alecthomas/kingpin/v2"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+// snapshotGCFlags binds the `snapshot gc`/`snapshot forget` flags that control which
+// manifests are considered for deletion.
+type snapshotGCFlags struct {
+	groupBy groupByFlag
+}
+
+func (c *snapshotGCFlags) setup(cmd *kingpin.CmdClause) {
+	c.groupBy.setup(cmd)
+}
+
+// manifestsToDelete applies rp.Grouping (overridden by --group-by when set) and
+// returns the manifests that are not retained by any rule.
+func (c *snapshotGCFlags) manifestsToDelete(rp *snapshot.RetentionPolicy, manifests []*snapshot.Manifest) ([]*snapshot.Manifest, error) {
+	if err := c.groupBy.apply(rp); err != nil {
+		return nil, err
+	}
+
+	return rp.ManifestsToDelete(manifests), nil
+}
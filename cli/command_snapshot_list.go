@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/kingpin/v2"
+
+	"github.com/kopia/kopia/snapshot"
+)
+
+// snapshotListFlags binds the flags controlling how `snapshot list` groups and
+// annotates the manifests it prints.
+type snapshotListFlags struct {
+	groupBy     groupByFlag
+	showReasons bool
+}
+
+func (c *snapshotListFlags) setup(cmd *kingpin.CmdClause) {
+	c.groupBy.setup(cmd)
+	cmd.Flag("show-reasons", "Show the retention reasons (including within/within-daily/... and tagged:<tag>) next to each snapshot").BoolVar(&c.showReasons)
+}
+
+// describe formats s for display, appending its retention reasons when
+// --show-reasons was passed.
+func (c *snapshotListFlags) describe(s *snapshot.Manifest) string {
+	if !c.showReasons || len(s.RetentionReasons) == 0 {
+		return string(s.ID)
+	}
+
+	return fmt.Sprintf("%v (%v)", s.ID, strings.Join(s.RetentionReasons, ","))
+}
+
+// applyGrouping overrides rp.Grouping with the one selected by --group-by, if any,
+// before rp.ComputeRetentionReasons is used to annotate the manifests being listed.
+func (c *snapshotListFlags) applyGrouping(rp *snapshot.RetentionPolicy) error {
+	return c.groupBy.apply(rp)
+}
@@ -0,0 +1,90 @@
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ActionRequest is the JSON document kopia writes to an action's stdin when the
+// action's Protocol is ActionProtocolJSON.
+type ActionRequest struct {
+	SnapshotID      string   `json:"snapshotId,omitempty"`
+	SourcePath      string   `json:"sourcePath,omitempty"`
+	Host            string   `json:"host,omitempty"`
+	PriorSnapshotID string   `json:"priorSnapshotId,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+}
+
+// ActionResponse is the JSON document kopia reads from an action's stdout when the
+// action's Protocol is ActionProtocolJSON.
+type ActionResponse struct {
+	RedirectPath string   `json:"redirectPath,omitempty"`
+	ExtraTags    []string `json:"extraTags,omitempty"`
+	AddExcludes  []string `json:"addExcludes,omitempty"`
+	Abort        bool     `json:"abort,omitempty"`
+	AbortReason  string   `json:"abortReason,omitempty"`
+}
+
+// RunJSONAction runs a's script using the JSON action protocol: req is marshaled to
+// the action's stdin and its stdout is parsed as an ActionResponse. In async mode the
+// response is ignored, matching the behavior of the legacy environment protocol.
+func RunJSONAction(ctx context.Context, a *ActionCommand, req ActionRequest) (*ActionResponse, error) {
+	if a == nil {
+		return nil, nil
+	}
+
+	reqBytes, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling action request: %w", err)
+	}
+
+	timeout := defaultActionTimeout
+	if a.TimeoutSeconds > 0 {
+		timeout = time.Duration(a.TimeoutSeconds) * time.Second
+	}
+
+	if a.Mode == ActionCommandModeAsync {
+		go runJSONActionScript(ctx, a, timeout, reqBytes) //nolint:errcheck
+
+		return nil, nil
+	}
+
+	resp, err := runJSONActionScript(ctx, a, timeout, reqBytes)
+	if err != nil && a.Mode == ActionCommandModeOptional {
+		return nil, nil
+	}
+
+	return resp, err
+}
+
+func runJSONActionScript(ctx context.Context, a *ActionCommand, timeout time.Duration, reqBytes []byte) (*ActionResponse, error) {
+	cctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	c, cleanup, err := actionCommand(cctx, a)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	c.Stdin = bytes.NewReader(reqBytes)
+
+	var stdout bytes.Buffer
+
+	c.Stdout = &stdout
+
+	if err := c.Run(); err != nil {
+		return nil, fmt.Errorf("action failed: %w", err)
+	}
+
+	var resp ActionResponse
+
+	if err := json.NewDecoder(&stdout).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("parsing action response: %w", err)
+	}
+
+	return &resp, nil
+}
@@ -0,0 +1,118 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Environment variable names passed to action hooks.
+const (
+	EnvErrorMessage   = "KOPIA_ERROR_MESSAGE"
+	EnvErrorStage     = "KOPIA_ERROR_STAGE"
+	EnvSnapshotStatus = "KOPIA_SNAPSHOT_STATUS"
+	EnvSnapshotID     = "KOPIA_SNAPSHOT_ID"
+	EnvSnapshotPath   = "KOPIA_SNAPSHOT_PATH"
+)
+
+// Values of KOPIA_SNAPSHOT_STATUS passed to the on-snapshot-completion action.
+const (
+	SnapshotStatusSuccess = "success"
+	SnapshotStatusFailed  = "failed"
+)
+
+const defaultActionTimeout = 5 * time.Minute
+
+// RunOnSnapshotError invokes the on-snapshot-error action, if configured, passing the
+// failed stage and error message as environment variables.
+func RunOnSnapshotError(ctx context.Context, a *ActionCommand, stage, errMessage string) error {
+	if a == nil {
+		return nil
+	}
+
+	return runCommand(ctx, a, []string{
+		EnvErrorStage + "=" + stage,
+		EnvErrorMessage + "=" + errMessage,
+	})
+}
+
+// RunOnSnapshotCompletion invokes the on-snapshot-completion action, if configured,
+// passing whether the snapshot succeeded or failed. Unlike the other hooks, it always
+// runs, on both the success and the failure path.
+func RunOnSnapshotCompletion(ctx context.Context, a *ActionCommand, succeeded bool) error {
+	if a == nil {
+		return nil
+	}
+
+	status := SnapshotStatusFailed
+	if succeeded {
+		status = SnapshotStatusSuccess
+	}
+
+	return runCommand(ctx, a, []string{EnvSnapshotStatus + "=" + status})
+}
+
+// RunBeforeRepositoryConnect invokes the before-repository-connect action, if
+// configured. It is stored in the global policy and runs before the repository is
+// connected, e.g. to mount the storage backing it.
+func RunBeforeRepositoryConnect(ctx context.Context, a *ActionCommand) error {
+	if a == nil {
+		return nil
+	}
+
+	return runCommand(ctx, a, nil)
+}
+
+// RunAfterRepositoryDisconnect invokes the after-repository-disconnect action, if
+// configured, e.g. to unmount the storage backing the repository.
+func RunAfterRepositoryDisconnect(ctx context.Context, a *ActionCommand) error {
+	if a == nil {
+		return nil
+	}
+
+	return runCommand(ctx, a, nil)
+}
+
+// runCommand runs a's script with the given extra environment variables, applying
+// a's command-mode and timeout semantics.
+func runCommand(ctx context.Context, a *ActionCommand, extraEnv []string) error {
+	timeout := defaultActionTimeout
+	if a.TimeoutSeconds > 0 {
+		timeout = time.Duration(a.TimeoutSeconds) * time.Second
+	}
+
+	run := func() error {
+		cctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		c, cleanup, err := actionCommand(cctx, a)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+
+		c.Env = append(os.Environ(), extraEnv...)
+
+		return c.Run()
+	}
+
+	switch a.Mode {
+	case ActionCommandModeAsync:
+		go run() //nolint:errcheck
+
+		return nil
+
+	case ActionCommandModeOptional:
+		run() //nolint:errcheck
+
+		return nil
+
+	default: // essential
+		if err := run(); err != nil {
+			return fmt.Errorf("action failed: %w", err)
+		}
+
+		return nil
+	}
+}
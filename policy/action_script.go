@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// actionCommand returns the exec.Cmd that runs a's script, honoring
+// a.PersistScript, and a cleanup function that must be called once the command has
+// finished (it removes the temporary file created for a persisted script, if any).
+func actionCommand(ctx context.Context, a *ActionCommand) (*exec.Cmd, func(), error) {
+	noop := func() {}
+
+	if !a.PersistScript {
+		return exec.CommandContext(ctx, "sh", "-c", a.Script), noop, nil //nolint:gosec
+	}
+
+	f, err := os.CreateTemp("", "kopia-action-*")
+	if err != nil {
+		return nil, noop, fmt.Errorf("creating action script file: %w", err)
+	}
+
+	cleanup := func() { os.Remove(f.Name()) } //nolint:errcheck
+
+	if _, err := f.WriteString(a.Script); err != nil {
+		f.Close() //nolint:errcheck,gosec
+		cleanup()
+
+		return nil, noop, fmt.Errorf("writing action script file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		cleanup()
+		return nil, noop, fmt.Errorf("closing action script file: %w", err)
+	}
+
+	if err := os.Chmod(f.Name(), 0o700); err != nil {
+		cleanup()
+		return nil, noop, fmt.Errorf("making action script file executable: %w", err)
+	}
+
+	return exec.CommandContext(ctx, f.Name()), cleanup, nil //nolint:gosec
+}
@@ -0,0 +1,93 @@
+// Package policy defines snapshot and repository policies, including the action
+// hooks that may be run around snapshot and repository connection lifecycle events.
+package policy
+
+// ActionCommandMode controls how an action's exit status affects the operation that
+// triggered it.
+type ActionCommandMode string
+
+// Supported action command modes.
+const (
+	// ActionCommandModeEssential causes the triggering operation to fail if the
+	// action fails or times out.
+	ActionCommandModeEssential ActionCommandMode = "essential"
+
+	// ActionCommandModeOptional runs the action but ignores its failure.
+	ActionCommandModeOptional ActionCommandMode = "optional"
+
+	// ActionCommandModeAsync starts the action and does not wait for it to finish,
+	// nor does it look at its output.
+	ActionCommandModeAsync ActionCommandMode = "async"
+)
+
+// ActionProtocol selects how an action receives its input and how its output is
+// interpreted.
+type ActionProtocol string
+
+// Supported action protocols.
+const (
+	// ActionProtocolEnvironment is the default, legacy protocol: kopia parses
+	// KEY=value lines from the action's stdout.
+	ActionProtocolEnvironment ActionProtocol = "env"
+
+	// ActionProtocolJSON sends a JSON ActionRequest on the action's stdin and reads
+	// a JSON ActionResponse from its stdout.
+	ActionProtocolJSON ActionProtocol = "json"
+)
+
+// ActionCommand describes a single action hook: the command to run and how to run
+// it.
+type ActionCommand struct {
+	Script         string            `json:"script,omitempty"`
+	TimeoutSeconds int               `json:"timeout,omitempty"`
+	Mode           ActionCommandMode `json:"mode,omitempty"`
+
+	// Protocol selects how the action's input/output is interpreted. Defaults to
+	// ActionProtocolEnvironment when empty.
+	Protocol ActionProtocol `json:"protocol,omitempty"`
+
+	// PersistScript causes Script to be written to a temporary executable file and
+	// run from there instead of being passed inline to the shell (see
+	// `policy set --persist-action-script`), which some actions need, e.g. so that
+	// `#!/usr/bin/env python3`-style shebangs are honored.
+	PersistScript bool `json:"persistScript,omitempty"`
+}
+
+// ActionsPolicy describes actions to be invoked when taking snapshots.
+type ActionsPolicy struct {
+	BeforeSnapshotRoot *ActionCommand `json:"beforeSnapshotRoot,omitempty"`
+	AfterSnapshotRoot  *ActionCommand `json:"afterSnapshotRoot,omitempty"`
+	BeforeFolder       *ActionCommand `json:"beforeFolder,omitempty"`
+	AfterFolder        *ActionCommand `json:"afterFolder,omitempty"`
+
+	// OnSnapshotError runs when snapshot creation fails, receiving the
+	// KOPIA_ERROR_MESSAGE and KOPIA_ERROR_STAGE environment variables.
+	OnSnapshotError *ActionCommand `json:"onSnapshotError,omitempty"`
+
+	// OnSnapshotCompletion always runs after a snapshot attempt, whether it
+	// succeeded or failed, receiving KOPIA_SNAPSHOT_STATUS.
+	OnSnapshotCompletion *ActionCommand `json:"onSnapshotCompletion,omitempty"`
+
+	// BeforeRepositoryConnect and AfterRepositoryDisconnect are repository-level
+	// hooks stored in the global policy, typically used to mount/unmount the
+	// storage backing the repository.
+	BeforeRepositoryConnect   *ActionCommand `json:"beforeRepositoryConnect,omitempty"`
+	AfterRepositoryDisconnect *ActionCommand `json:"afterRepositoryDisconnect,omitempty"`
+}
+
+func mergeActionCommand(dst, src **ActionCommand) {
+	if *dst == nil {
+		*dst = *src
+	}
+}
+
+func mergeActionsPolicy(dst, src *ActionsPolicy) {
+	mergeActionCommand(&dst.BeforeSnapshotRoot, &src.BeforeSnapshotRoot)
+	mergeActionCommand(&dst.AfterSnapshotRoot, &src.AfterSnapshotRoot)
+	mergeActionCommand(&dst.BeforeFolder, &src.BeforeFolder)
+	mergeActionCommand(&dst.AfterFolder, &src.AfterFolder)
+	mergeActionCommand(&dst.OnSnapshotError, &src.OnSnapshotError)
+	mergeActionCommand(&dst.OnSnapshotCompletion, &src.OnSnapshotCompletion)
+	mergeActionCommand(&dst.BeforeRepositoryConnect, &src.BeforeRepositoryConnect)
+	mergeActionCommand(&dst.AfterRepositoryDisconnect, &src.AfterRepositoryDisconnect)
+}
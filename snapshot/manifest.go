@@ -0,0 +1,55 @@
+package snapshot
+
+import (
+	"sort"
+	"time"
+)
+
+// ID is the identifier of a snapshot manifest.
+type ID string
+
+// SourceInfo represents the information about snapshot source.
+type SourceInfo struct {
+	Host     string `json:"host"`
+	UserName string `json:"userName"`
+	Path     string `json:"path"`
+}
+
+// Manifest represents information about a single snapshot.
+type Manifest struct {
+	ID               ID         `json:"id"`
+	Source           SourceInfo `json:"source"`
+	Description      string     `json:"description"`
+	StartTime        time.Time  `json:"startTime"`
+	EndTime          time.Time  `json:"endTime"`
+	IncompleteReason string     `json:"incomplete,omitempty"`
+
+	// Tags are arbitrary user-assigned labels attached to the snapshot at creation
+	// time (see `snapshot create --tags`). A snapshot bearing a tag listed in the
+	// policy's KeepTags is retained unconditionally, see RetentionPolicy.KeepTags.
+	Tags []string `json:"tags,omitempty"`
+
+	// RetentionReasons is populated by RetentionPolicy.ComputeRetentionReasons and is
+	// not persisted as part of the manifest itself.
+	RetentionReasons []string `json:"-"`
+}
+
+// SortByTime returns manifests sorted by StartTime. If reverse is true, the newest
+// manifest comes first.
+func SortByTime(manifests []*Manifest, reverse bool) []*Manifest {
+	result := append([]*Manifest(nil), manifests...)
+
+	sort.Slice(result, func(i, j int) bool {
+		if reverse {
+			return result[i].StartTime.After(result[j].StartTime)
+		}
+
+		return result[i].StartTime.Before(result[j].StartTime)
+	})
+
+	return result
+}
+
+func intPtr(n int) *int {
+	return &n
+}
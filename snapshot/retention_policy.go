@@ -2,6 +2,8 @@ package snapshot
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -13,6 +15,114 @@ type RetentionPolicy struct {
 	KeepWeekly  *int `json:"keepWeekly,omitempty"`
 	KeepMonthly *int `json:"keepMonthly,omitempty"`
 	KeepAnnual  *int `json:"keepAnnual,omitempty"`
+
+	// KeepWithin unconditionally retains every snapshot newer than (now - KeepWithin),
+	// regardless of the counters above. KeepWithinHourly/Daily/Weekly/Monthly/Annual
+	// retain the newest snapshot of each hour/day/week/month/year that starts within
+	// the given duration, independently of the counters above, so that a policy can
+	// express "keep everything for X, then thin out".
+	KeepWithin        *time.Duration `json:"keepWithin,omitempty"`
+	KeepWithinHourly  *time.Duration `json:"keepWithinHourly,omitempty"`
+	KeepWithinDaily   *time.Duration `json:"keepWithinDaily,omitempty"`
+	KeepWithinWeekly  *time.Duration `json:"keepWithinWeekly,omitempty"`
+	KeepWithinMonthly *time.Duration `json:"keepWithinMonthly,omitempty"`
+	KeepWithinAnnual  *time.Duration `json:"keepWithinAnnual,omitempty"`
+
+	// Grouping, when set, partitions manifests into independent groups before
+	// applying the counters above, so that e.g. KeepDaily=7 is enforced separately
+	// for each host/path/tag instead of across all snapshots passed in.
+	Grouping *RetentionGrouping `json:"grouping,omitempty"`
+
+	// KeepTags unconditionally retains any snapshot that carries at least one of
+	// these tags, regardless of the counters above.
+	KeepTags []string `json:"keepTags,omitempty"`
+
+	// KeepWeeklyOffset identifies the weekday (time.Sunday == 0 .. time.Saturday == 6)
+	// of the snapshot retained by KeepWeekly within each ISO week. When unset, the
+	// retained snapshot is simply the newest one seen in that ISO week.
+	KeepWeeklyOffset *int `json:"keepWeeklyOffset,omitempty"`
+}
+
+// RetentionGrouping selects the dimensions used to partition manifests before
+// retention counters are applied. Manifests that share a value across all selected
+// dimensions are grouped together and compete for the same KeepXxx counters; manifests
+// in different groups are retained independently of one another.
+type RetentionGrouping struct {
+	ByHost     bool     `json:"byHost,omitempty"`
+	ByUsername bool     `json:"byUsername,omitempty"`
+	ByPath     bool     `json:"byPath,omitempty"`
+	ByTags     []string `json:"byTags,omitempty"`
+
+	// ByAllTags groups by a manifest's full, sorted tag set, as opposed to ByTags,
+	// which groups by the presence or absence of specific named tags.
+	ByAllTags bool `json:"byAllTags,omitempty"`
+}
+
+// groupKey returns the grouping key for the given manifest under g. Manifests that
+// are identical in the dimensions g selects share a key and therefore a group.
+func (g *RetentionGrouping) groupKey(s *Manifest) string {
+	if g == nil {
+		return ""
+	}
+
+	var parts []string
+
+	if g.ByHost {
+		parts = append(parts, "host="+s.Source.Host)
+	}
+
+	if g.ByUsername {
+		parts = append(parts, "user="+s.Source.UserName)
+	}
+
+	if g.ByPath {
+		parts = append(parts, "path="+s.Source.Path)
+	}
+
+	for _, tag := range g.ByTags {
+		parts = append(parts, fmt.Sprintf("tag:%v=%v", tag, hasTag(s.Tags, tag)))
+	}
+
+	if g.ByAllTags {
+		sortedTags := append([]string(nil), s.Tags...)
+		sort.Strings(sortedTags)
+		parts = append(parts, "tags="+strings.Join(sortedTags, ","))
+	}
+
+	return strings.Join(parts, "\x00")
+}
+
+// hasTag returns true if tags contains tag.
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unboundedCount is used as the per-bucket limit for within-duration retention cases,
+// which are bounded by a cutoff time rather than by a count.
+const unboundedCount = int(^uint(0) >> 1)
+
+// ManifestsToDelete returns the manifests in manifests that ComputeRetentionReasons
+// determines are not retained by any rule, honoring r.Grouping exactly as
+// ComputeRetentionReasons does. snapshot gc and snapshot forget call this to decide
+// what to delete.
+func (r *RetentionPolicy) ManifestsToDelete(manifests []*Manifest) []*Manifest {
+	r.ComputeRetentionReasons(manifests)
+
+	var toDelete []*Manifest
+
+	for _, s := range manifests {
+		if len(s.RetentionReasons) == 0 {
+			toDelete = append(toDelete, s)
+		}
+	}
+
+	return toDelete
 }
 
 // ComputeRetentionReasons computes the reasons why each snapshot is retained, based on
@@ -29,32 +139,135 @@ func (r *RetentionPolicy) ComputeRetentionReasons(manifests []*Manifest) {
 		return maxTime
 	}
 
+	durationCutoffTime := func(setting *time.Duration) time.Time {
+		if setting != nil {
+			return now.Add(-*setting)
+		}
+
+		return maxTime
+	}
+
 	cutoff := cutoffTimes{
 		annual:  cutoffTime(r.KeepAnnual, yearsAgo),
 		monthly: cutoffTime(r.KeepMonthly, monthsAgo),
 		daily:   cutoffTime(r.KeepDaily, daysAgo),
 		hourly:  cutoffTime(r.KeepHourly, hoursAgo),
-		weekly:  cutoffTime(r.KeepHourly, weeksAgo),
+		weekly:  cutoffTime(r.KeepWeekly, weeksAgo),
+
+		within:        durationCutoffTime(r.KeepWithin),
+		withinHourly:  durationCutoffTime(r.KeepWithinHourly),
+		withinDaily:   durationCutoffTime(r.KeepWithinDaily),
+		withinWeekly:  durationCutoffTime(r.KeepWithinWeekly),
+		withinMonthly: durationCutoffTime(r.KeepWithinMonthly),
+		withinAnnual:  durationCutoffTime(r.KeepWithinAnnual),
+	}
+
+	sorted := SortByTime(manifests, true)
+
+	groups := map[string][]*Manifest{}
+
+	var groupOrder []string
+
+	for _, s := range sorted {
+		k := r.Grouping.groupKey(s)
+		if _, ok := groups[k]; !ok {
+			groupOrder = append(groupOrder, k)
+		}
+
+		groups[k] = append(groups[k], s)
+	}
+
+	for _, k := range groupOrder {
+		ids := make(map[string]bool)
+		idCounters := make(map[string]int)
+		weeklyReps := weeklyRepresentatives(groups[k], r.KeepWeeklyOffset)
+
+		for i, s := range groups[k] {
+			s.RetentionReasons = r.getRetentionReasons(i, s, cutoff, ids, idCounters, weeklyReps)
+		}
+	}
+}
+
+// weeklyRepresentatives picks, for each ISO week present in manifests, the single
+// manifest eligible for weekly retention: the one closest to the KeepWeeklyOffset
+// weekday (ties broken in favor of the newest). Returns nil when offset is unset, in
+// which case weekly retention falls back to its offset-less behavior (newest of the
+// week).
+func weeklyRepresentatives(manifests []*Manifest, offset *int) map[string]*Manifest {
+	if offset == nil {
+		return nil
+	}
+
+	reps := map[string]*Manifest{}
+	dists := map[string]int{}
+
+	for _, s := range manifests {
+		if s.IncompleteReason != "" {
+			continue
+		}
+
+		yyyy, wk := s.StartTime.ISOWeek()
+		key := fmt.Sprintf("%04v-%02v", yyyy, wk)
+		dist := weekdayDistance(s.StartTime.Weekday(), time.Weekday(*offset))
+
+		cur, ok := reps[key]
+		if !ok || dist < dists[key] || (dist == dists[key] && s.StartTime.After(cur.StartTime)) {
+			reps[key] = s
+			dists[key] = dist
+		}
 	}
 
-	ids := make(map[string]bool)
-	idCounters := make(map[string]int)
+	return reps
+}
+
+// weekdayDistance returns the number of days (0-3) between two weekdays going the
+// shorter way around the 7-day week.
+func weekdayDistance(a, b time.Weekday) int {
+	d := int(a) - int(b)
+	if d < 0 {
+		d = -d
+	}
 
-	for i, s := range SortByTime(manifests, true) {
-		s.RetentionReasons = r.getRetentionReasons(i, s, cutoff, ids, idCounters)
+	if d > 3 { //nolint:gomnd
+		d = 7 - d
 	}
+
+	return d
 }
 
-func (r *RetentionPolicy) getRetentionReasons(i int, s *Manifest, cutoff cutoffTimes, ids map[string]bool, idCounters map[string]int) []string {
+func (r *RetentionPolicy) getRetentionReasons(i int, s *Manifest, cutoff cutoffTimes, ids map[string]bool, idCounters map[string]int, weeklyReps map[string]*Manifest) []string {
 	if s.IncompleteReason != "" {
 		return nil
 	}
 
 	var keepReasons []string
+
+	for _, tag := range s.Tags {
+		if hasTag(r.KeepTags, tag) {
+			keepReasons = append(keepReasons, "tagged:"+tag)
+		}
+	}
+
+	if keepReasons != nil {
+		return keepReasons
+	}
+
 	var zeroTime time.Time
 
 	yyyy, wk := s.StartTime.ISOWeek()
 
+	unbounded := unboundedCount
+
+	// When KeepWeeklyOffset is set, only the chosen representative of the ISO week
+	// (the snapshot closest to that weekday, see weeklyRepresentatives) is eligible
+	// for weekly retention; otherwise the newest snapshot of the week (the existing,
+	// offset-less behavior) is used. This always picks exactly one snapshot per
+	// week, even if none falls exactly on the configured weekday.
+	weeklyMax := r.KeepWeekly
+	if r.KeepWeeklyOffset != nil && weeklyReps[fmt.Sprintf("%04v-%02v", yyyy, wk)] != s {
+		weeklyMax = nil
+	}
+
 	cases := []struct {
 		cutoffTime     time.Time
 		timePeriodID   string
@@ -64,9 +277,15 @@ func (r *RetentionPolicy) getRetentionReasons(i int, s *Manifest, cutoff cutoffT
 		{zeroTime, fmt.Sprintf("%v", i), "latest", r.KeepLatest},
 		{cutoff.annual, s.StartTime.Format("2006"), "annual", r.KeepAnnual},
 		{cutoff.monthly, s.StartTime.Format("2006-01"), "monthly", r.KeepMonthly},
-		{cutoff.weekly, fmt.Sprintf("%04v-%02v", yyyy, wk), "weekly", r.KeepWeekly},
+		{cutoff.weekly, fmt.Sprintf("%04v-%02v", yyyy, wk), "weekly", weeklyMax},
 		{cutoff.daily, s.StartTime.Format("2006-01-02"), "daily", r.KeepDaily},
 		{cutoff.hourly, s.StartTime.Format("2006-01-02 15"), "hourly", r.KeepHourly},
+		{cutoff.within, fmt.Sprintf("within-%v", i), "within", durationMax(r.KeepWithin, unbounded)},
+		{cutoff.withinHourly, "within-hourly-" + s.StartTime.Format("2006-01-02 15"), "within-hourly", durationMax(r.KeepWithinHourly, unbounded)},
+		{cutoff.withinDaily, "within-daily-" + s.StartTime.Format("2006-01-02"), "within-daily", durationMax(r.KeepWithinDaily, unbounded)},
+		{cutoff.withinWeekly, fmt.Sprintf("within-weekly-%04v-%02v", yyyy, wk), "within-weekly", durationMax(r.KeepWithinWeekly, unbounded)},
+		{cutoff.withinMonthly, "within-monthly-" + s.StartTime.Format("2006-01"), "within-monthly", durationMax(r.KeepWithinMonthly, unbounded)},
+		{cutoff.withinAnnual, "within-annual-" + s.StartTime.Format("2006"), "within-annual", durationMax(r.KeepWithinAnnual, unbounded)},
 	}
 
 	for _, c := range cases {
@@ -97,6 +316,25 @@ type cutoffTimes struct {
 	daily   time.Time
 	hourly  time.Time
 	weekly  time.Time
+
+	within        time.Time
+	withinHourly  time.Time
+	withinDaily   time.Time
+	withinWeekly  time.Time
+	withinMonthly time.Time
+	withinAnnual  time.Time
+}
+
+// durationMax returns a pointer to an effectively-unbounded count when the given
+// duration setting is non-nil, and nil otherwise, so that within-duration retention
+// cases can be plugged into the same cutoff/max-count case mechanism used by the
+// bucketed counters above.
+func durationMax(setting *time.Duration, unbounded int) *int {
+	if setting == nil {
+		return nil
+	}
+
+	return &unbounded
 }
 
 func yearsAgo(base time.Time, n int) time.Time {
@@ -147,4 +385,31 @@ func mergeRetentionPolicy(dst, src *RetentionPolicy) {
 	if dst.KeepAnnual == nil {
 		dst.KeepAnnual = src.KeepAnnual
 	}
-}
\ No newline at end of file
+	if dst.KeepWithin == nil {
+		dst.KeepWithin = src.KeepWithin
+	}
+	if dst.KeepWithinHourly == nil {
+		dst.KeepWithinHourly = src.KeepWithinHourly
+	}
+	if dst.KeepWithinDaily == nil {
+		dst.KeepWithinDaily = src.KeepWithinDaily
+	}
+	if dst.KeepWithinWeekly == nil {
+		dst.KeepWithinWeekly = src.KeepWithinWeekly
+	}
+	if dst.KeepWithinMonthly == nil {
+		dst.KeepWithinMonthly = src.KeepWithinMonthly
+	}
+	if dst.KeepWithinAnnual == nil {
+		dst.KeepWithinAnnual = src.KeepWithinAnnual
+	}
+	if dst.Grouping == nil {
+		dst.Grouping = src.Grouping
+	}
+	if dst.KeepTags == nil {
+		dst.KeepTags = src.KeepTags
+	}
+	if dst.KeepWeeklyOffset == nil {
+		dst.KeepWeeklyOffset = src.KeepWeeklyOffset
+	}
+}
@@ -0,0 +1,68 @@
+package snapshot
+
+import (
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, layout, value string) time.Time {
+	t.Helper()
+
+	tm, err := time.Parse(layout, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tm
+}
+
+func TestWeeklyRetentionAcrossYearBoundary(t *testing.T) {
+	// 2020-12-28 (Mon) through 2021-01-03 (Sun) are all ISO week 2020-W53.
+	manifests := []*Manifest{
+		{StartTime: mustParse(t, "2006-01-02", "2020-12-28")},
+		{StartTime: mustParse(t, "2006-01-02", "2020-12-31")},
+		{StartTime: mustParse(t, "2006-01-02", "2021-01-03")},
+		{StartTime: mustParse(t, "2006-01-02", "2021-01-04")}, // ISO week 2021-W01
+	}
+
+	// KeepWeekly is large enough that the weekly cutoff (now minus KeepWeekly weeks)
+	// reaches back before these fixed dates.
+	r := &RetentionPolicy{KeepWeekly: intPtr(10000)}
+	r.ComputeRetentionReasons(manifests)
+
+	if got, want := len(manifests[0].RetentionReasons), 0; got != want {
+		t.Errorf("2020-12-28 got %v reasons, want %v (superseded by newer same-week snapshot)", manifests[0].RetentionReasons, want)
+	}
+
+	if got := manifests[2].RetentionReasons; len(got) == 0 {
+		t.Errorf("2021-01-03, the newest snapshot of ISO week 2020-W53, was not retained")
+	}
+
+	if got := manifests[3].RetentionReasons; len(got) == 0 {
+		t.Errorf("2021-01-04, in ISO week 2021-W01, was not retained as a separate week")
+	}
+}
+
+func TestKeepWeeklyOffsetPicksNearestWeekday(t *testing.T) {
+	// Monday 2021-02-01 through Sunday 2021-02-07, all ISO week 2021-W05. No
+	// snapshot falls exactly on the configured Monday offset.
+	tue := mustParse(t, "2006-01-02", "2021-02-02")
+	thu := mustParse(t, "2006-01-02", "2021-02-04")
+
+	manifests := []*Manifest{
+		{StartTime: tue},
+		{StartTime: thu},
+	}
+
+	monday := int(time.Monday)
+	r := &RetentionPolicy{KeepWeekly: intPtr(10000), KeepWeeklyOffset: &monday}
+	r.ComputeRetentionReasons(manifests)
+
+	if len(manifests[0].RetentionReasons) == 0 {
+		t.Errorf("Tuesday (closer to Monday) should have been retained as the weekly representative")
+	}
+
+	if len(manifests[1].RetentionReasons) != 0 {
+		t.Errorf("Thursday (farther from Monday) should not have been retained as the weekly representative")
+	}
+}
@@ -20,7 +20,7 @@ func TestSnapshotActionsBeforeSnapshotRoot(t *testing.T) {
 
 	th := os.Getenv("TESTING_ACTION_EXE")
 	if th == "" {
-		t.Skip("TESTING_ACTION_EXE verifyNoError be set")
+		t.Skip("TESTING_ACTION_EXE must be set")
 	}
 
 	e := testenv.NewCLITest(t)
@@ -154,7 +154,7 @@ func TestSnapshotActionsBeforeAfterFolder(t *testing.T) {
 
 	th := os.Getenv("TESTING_ACTION_EXE")
 	if th == "" {
-		t.Skip("TESTING_ACTION_EXE verifyNoError be set")
+		t.Skip("TESTING_ACTION_EXE must be set")
 	}
 
 	e := testenv.NewCLITest(t)
@@ -272,7 +272,7 @@ func TestSnapshotActionsEnable(t *testing.T) {
 
 	th := os.Getenv("TESTING_ACTION_EXE")
 	if th == "" {
-		t.Skip("TESTING_ACTION_EXE verifyNoError be set")
+		t.Skip("TESTING_ACTION_EXE must be set")
 	}
 
 	cases := []struct {
@@ -322,6 +322,158 @@ func TestSnapshotActionsEnable(t *testing.T) {
 	}
 }
 
+func TestSnapshotActionsOnError(t *testing.T) {
+	t.Parallel()
+
+	th := os.Getenv("TESTING_ACTION_EXE")
+	if th == "" {
+		t.Skip("TESTING_ACTION_EXE must be set")
+	}
+
+	e := testenv.NewCLITest(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--enable-actions")
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	envFile := filepath.Join(e.LogsDir, "on-error-env.txt")
+
+	// an action that always fails will be run as before-snapshot-root and that
+	// failure should fail the snapshot and trigger on-snapshot-error.
+	e.RunAndExpectSuccess(t,
+		"policy", "set", sharedTestDataDir1,
+		"--before-snapshot-root-action", th+" --exit-code=3",
+		"--on-snapshot-error-action", th+" --save-env="+envFile)
+
+	e.RunAndExpectFailure(t, "snapshot", "create", sharedTestDataDir1)
+
+	env := mustReadEnvFile(t, envFile)
+	if env["KOPIA_ERROR_STAGE"] == "" {
+		t.Errorf("KOPIA_ERROR_STAGE was not passed to on-snapshot-error action")
+	}
+
+	if env["KOPIA_ERROR_MESSAGE"] == "" {
+		t.Errorf("KOPIA_ERROR_MESSAGE was not passed to on-snapshot-error action")
+	}
+}
+
+func TestSnapshotActionsOnCompletion(t *testing.T) {
+	t.Parallel()
+
+	th := os.Getenv("TESTING_ACTION_EXE")
+	if th == "" {
+		t.Skip("TESTING_ACTION_EXE must be set")
+	}
+
+	e := testenv.NewCLITest(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--enable-actions")
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	successEnvFile := filepath.Join(e.LogsDir, "on-completion-success-env.txt")
+
+	e.RunAndExpectSuccess(t,
+		"policy", "set", sharedTestDataDir1,
+		"--on-snapshot-completion-action", th+" --save-env="+successEnvFile)
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+
+	if got, want := mustReadEnvFile(t, successEnvFile)["KOPIA_SNAPSHOT_STATUS"], "success"; got != want {
+		t.Errorf("unexpected KOPIA_SNAPSHOT_STATUS: %v, want %v", got, want)
+	}
+
+	failureEnvFile := filepath.Join(e.LogsDir, "on-completion-failure-env.txt")
+
+	e.RunAndExpectSuccess(t,
+		"policy", "set", sharedTestDataDir1,
+		"--before-snapshot-root-action", th+" --exit-code=3",
+		"--on-snapshot-completion-action", th+" --save-env="+failureEnvFile)
+
+	e.RunAndExpectFailure(t, "snapshot", "create", sharedTestDataDir1)
+
+	if got, want := mustReadEnvFile(t, failureEnvFile)["KOPIA_SNAPSHOT_STATUS"], "failed"; got != want {
+		t.Errorf("unexpected KOPIA_SNAPSHOT_STATUS: %v, want %v", got, want)
+	}
+}
+
+func TestSnapshotActionsBeforeAfterRepository(t *testing.T) {
+	t.Parallel()
+
+	th := os.Getenv("TESTING_ACTION_EXE")
+	if th == "" {
+		t.Skip("TESTING_ACTION_EXE must be set")
+	}
+
+	e := testenv.NewCLITest(t)
+
+	connectEnvFile := filepath.Join(e.LogsDir, "before-repository-connect-env.txt")
+	disconnectEnvFile := filepath.Join(e.LogsDir, "after-repository-disconnect-env.txt")
+
+	// repository-level actions are stored in the global policy, which does not exist
+	// until the repository does, so the connect action only takes effect on the next
+	// connect, not the initial create.
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--enable-actions")
+	e.RunAndExpectSuccess(t, "policy", "set", "--global",
+		"--before-repository-connect-action", th+" --save-env="+connectEnvFile,
+		"--after-repository-disconnect-action", th+" --save-env="+disconnectEnvFile)
+	e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	verifyFileExists(t, disconnectEnvFile)
+
+	e.RunAndExpectSuccess(t, "repo", "connect", "filesystem", "--path", e.RepoDir)
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	verifyFileExists(t, connectEnvFile)
+}
+
+func TestSnapshotActionsJSONProtocol(t *testing.T) {
+	t.Parallel()
+
+	e := testenv.NewCLITest(t)
+
+	e.RunAndExpectSuccess(t, "repo", "create", "filesystem", "--path", e.RepoDir, "--enable-actions")
+	defer e.RunAndExpectSuccess(t, "repo", "disconnect")
+
+	// a script reading the JSON request from stdin and echoing back a JSON response
+	// redirecting the snapshot to sharedTestDataDir2 is only legal when
+	// --action-protocol=json is set - without it kopia would instead look for
+	// KOPIA_SNAPSHOT_PATH=... lines on stdout.
+	redirectScript := tmpfileWithContents(t, jsonProtocolScript(`{"redirectPath":"`+sharedTestDataDir2+`"}`))
+
+	e.RunAndExpectSuccess(t, "policy", "set", sharedTestDataDir1,
+		"--before-snapshot-root-action", redirectScript,
+		"--action-protocol=json",
+		"--persist-action-script")
+
+	e.RunAndExpectSuccess(t, "snapshot", "create", sharedTestDataDir1)
+
+	snaps1 := e.ListSnapshotsAndExpectSuccess(t, sharedTestDataDir1)[0].Snapshots
+	snaps2 := e.ListSnapshotsAndExpectSuccess(t, sharedTestDataDir2)[0].Snapshots
+
+	if got, want := snaps1[len(snaps1)-1].ObjectID, snaps2[0].ObjectID; got != want {
+		t.Fatalf("invalid snapshot ID after JSON-protocol redirection %v, wanted %v", got, want)
+	}
+
+	// a script that asks kopia to abort the snapshot.
+	abortScript := tmpfileWithContents(t, jsonProtocolScript(`{"abort":true,"abortReason":"disk full"}`))
+
+	e.RunAndExpectSuccess(t, "policy", "set", sharedTestDataDir1,
+		"--before-snapshot-root-action", abortScript,
+		"--action-protocol=json",
+		"--persist-action-script")
+
+	e.RunAndExpectFailure(t, "snapshot", "create", sharedTestDataDir1)
+}
+
+// jsonProtocolScript returns the contents of a shell script that reads (and discards)
+// the JSON request kopia writes to its stdin and echoes response to stdout.
+func jsonProtocolScript(response string) string {
+	if runtime.GOOS == "windows" {
+		return "@echo off\r\nfindstr \"^\" > nul\r\necho " + response
+	}
+
+	return "#!/bin/sh\ncat >/dev/null\necho '" + response + "'\n"
+}
+
 func tmpfileWithContents(t *testing.T, contents string) string {
 	t.Helper()
 